@@ -16,6 +16,7 @@ package graphics
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -43,10 +44,108 @@ const (
 )
 
 const (
-	IndicesCount     = (1 << 16) / 3 * 3 // Adjust num for triangles.
-	VertexFloatCount = 8
+	IndicesCount = (1 << 16) / 3 * 3 // Adjust num for triangles.
+
+	// builtinVertexFloatCount is the number of float32 values that Ebitengine's
+	// built-in vertex format always reserves: position (2), texture coordinate (2)
+	// and color (4). Attributes registered via SetVertexLayout are appended after
+	// these.
+	builtinVertexFloatCount = 8
 )
 
+// VertexAttribute describes one custom vertex attribute appended after
+// Ebitengine's built-in position, texture coordinate and color attributes.
+// A registered VertexAttribute is forwarded to the Kage shader frontend as an
+// additional attribute-style input.
+type VertexAttribute struct {
+	// Name is the attribute name as it is exposed to a Kage shader.
+	Name string
+
+	// Components is the number of float32 components the attribute occupies.
+	Components int
+
+	// Offset is the attribute's offset in float32 units from the start of a
+	// vertex. Offset is calculated and overwritten by SetVertexLayout; callers
+	// don't need to set it themselves.
+	Offset int
+}
+
+// VertexLayout describes the custom vertex attributes a game has registered
+// in addition to Ebitengine's built-in position, texture coordinate and
+// color attributes, e.g. a second UV set, per-vertex normals, or arbitrary
+// scalars like skinning weights or instance tints.
+type VertexLayout struct {
+	Attributes []VertexAttribute
+}
+
+var (
+	theVertexLayoutMu sync.Mutex
+	theVertexLayout   VertexLayout
+
+	// vertexFloatCount backs VertexFloatCount. It starts at
+	// builtinVertexFloatCount and is only written by SetVertexLayout, before
+	// any vertices are allocated.
+	vertexFloatCount atomic.Int32
+
+	// vertexLayoutFixed becomes true as soon as the first vertices are
+	// allocated, after which the layout can no longer change.
+	vertexLayoutFixed atomic.Bool
+)
+
+func init() {
+	vertexFloatCount.Store(builtinVertexFloatCount)
+}
+
+// VertexFloatCount returns the number of float32 values used for one vertex.
+// Its value is builtinVertexFloatCount plus the combined size of any
+// attributes registered via SetVertexLayout.
+func VertexFloatCount() int {
+	return int(vertexFloatCount.Load())
+}
+
+// SetVertexLayout registers additional per-vertex attributes that are
+// appended after Ebitengine's built-in position, texture coordinate and
+// color attributes. The returned layout's Attributes have their Offset
+// fields filled in.
+//
+// SetVertexLayout must be called before any vertices are allocated, e.g.
+// from a game's init function, since the stride of already-pooled vertices
+// can't change afterwards. Calling it after vertices have been allocated
+// causes a panic; the check is enforced atomically against concurrent calls
+// to Vertices and QuadVertices, not just documented. Indices is
+// stride-independent and doesn't participate in the check.
+func SetVertexLayout(layout VertexLayout) VertexLayout {
+	if vertexLayoutFixed.Load() {
+		panic("graphics: SetVertexLayout must be called before any vertices are allocated")
+	}
+
+	offset := builtinVertexFloatCount
+	for i := range layout.Attributes {
+		layout.Attributes[i].Offset = offset
+		offset += layout.Attributes[i].Components
+	}
+
+	theVertexLayoutMu.Lock()
+	defer theVertexLayoutMu.Unlock()
+
+	if vertexLayoutFixed.Load() {
+		panic("graphics: SetVertexLayout must be called before any vertices are allocated")
+	}
+
+	theVertexLayout = layout
+	vertexFloatCount.Store(int32(offset))
+	return theVertexLayout
+}
+
+// VertexLayoutAttributes returns the custom vertex attributes currently
+// registered via SetVertexLayout.
+func VertexLayoutAttributes() []VertexAttribute {
+	theVertexLayoutMu.Lock()
+	defer theVertexLayoutMu.Unlock()
+
+	return theVertexLayout.Attributes
+}
+
 var (
 	quadIndices = []uint16{0, 1, 2, 1, 2, 3}
 )
@@ -57,6 +156,7 @@ func QuadIndices() []uint16 {
 
 var (
 	theVerticesBackend = &verticesBackend{}
+	theIndicesBackend  = &indicesBackend{}
 )
 
 // TODO: The logic is very similar to atlas.temporaryPixels. Unify them.
@@ -70,7 +170,7 @@ type verticesBackend struct {
 }
 
 func verticesBackendFloat32Size(size int) int {
-	l := 128 * VertexFloatCount
+	l := 128 * VertexFloatCount()
 	for l < size {
 		l *= 2
 	}
@@ -88,7 +188,16 @@ func (v *verticesBackend) slice(n int) []float32 {
 	v.m.Lock()
 	defer v.m.Unlock()
 
-	need := n * VertexFloatCount
+	if !vertexLayoutFixed.Load() {
+		// Set the flag under theVertexLayoutMu so that a concurrent
+		// SetVertexLayout either completes before the stride is read below or
+		// sees the flag and panics.
+		theVertexLayoutMu.Lock()
+		vertexLayoutFixed.Store(true)
+		theVertexLayoutMu.Unlock()
+	}
+
+	need := n * VertexFloatCount()
 	if len(v.backend) < v.pos+need {
 		v.backend = make([]float32, max(len(v.backend)*2, verticesBackendFloat32Size(need)))
 		v.pos = 0
@@ -135,10 +244,114 @@ func LockAndResetVertices(f func() error) error {
 	return theVerticesBackend.lockAndReset(f)
 }
 
+// indicesBackend is a uint16 backend pooled the same way verticesBackend
+// pools float32s, so that caller-provided index buffers for non-quad
+// meshes don't need a fresh allocation per draw.
+type indicesBackend struct {
+	backend          []uint16
+	pos              int
+	notFullyUsedTime int
+
+	m sync.Mutex
+}
+
+func indicesBackendUint16Size(size int) int {
+	l := 128 * 3
+	for l < size {
+		l *= 2
+	}
+	return l
+}
+
+func (i *indicesBackend) slice(n int) []uint16 {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if len(i.backend) < i.pos+n {
+		i.backend = make([]uint16, max(len(i.backend)*2, indicesBackendUint16Size(n)))
+		i.pos = 0
+	}
+	s := i.backend[i.pos : i.pos+n]
+	i.pos += n
+	return s
+}
+
+func (i *indicesBackend) lockAndReset(f func() error) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if err := f(); err != nil {
+		return err
+	}
+
+	const maxNotFullyUsedTime = 60
+	if indicesBackendUint16Size(i.pos) < len(i.backend) {
+		if i.notFullyUsedTime < maxNotFullyUsedTime {
+			i.notFullyUsedTime++
+		}
+	} else {
+		i.notFullyUsedTime = 0
+	}
+
+	if i.notFullyUsedTime == maxNotFullyUsedTime && len(i.backend) > 0 {
+		i.backend = nil
+		i.notFullyUsedTime = 0
+	}
+
+	i.pos = 0
+	return nil
+}
+
+// Indices returns a uint16 slice for n indices.
+// Indices returns a slice that never overlaps with other slices returned this function,
+// and users can do optimization based on this fact.
+//
+// Indices is the counterpart to Vertices for submitting an arbitrary indexed
+// mesh instead of a fixed quad.
+func Indices(n int) []uint16 {
+	return theIndicesBackend.slice(n)
+}
+
+func LockAndResetIndices(f func() error) error {
+	return theIndicesBackend.lockAndReset(f)
+}
+
+// Mesh is a first-class entry point for submitting an arbitrary indexed
+// triangle mesh instead of a fixed quad: fill Vertices and Indices and hand
+// both to graphicscommand.EnqueueDrawTrianglesCommand.
+type Mesh struct {
+	// Vertices holds VertexFloatCount() float32 values per vertex.
+	Vertices []float32
+
+	// Indices indexes into Vertices, grouped into triangles.
+	Indices []uint16
+}
+
+// NewMesh returns a Mesh with pooled Vertices and Indices slices sized for
+// vertexCount vertices and indexCount indices. The caller fills both slices
+// before submitting the mesh.
+func NewMesh(vertexCount, indexCount int) Mesh {
+	return Mesh{
+		Vertices: Vertices(vertexCount),
+		Indices:  Indices(indexCount),
+	}
+}
+
+// QuadVertexFiller fills in the custom attributes registered via
+// SetVertexLayout for one corner of a quad submitted through QuadVertices.
+// corner is 0 for (sx0, sy0), 1 for (sx1, sy0), 2 for (sx0, sy1), and 3 for
+// (sx1, sy1). dst has length VertexFloatCount-builtinVertexFloatCount and
+// is backed by the same pooled slice QuadVertices returns.
+type QuadVertexFiller func(corner int, dst []float32)
+
 // QuadVertices returns a float32 slice for a quadrangle.
 // QuadVertices returns a slice that never overlaps with other slices returned this function,
 // and users can do optimization based on this fact.
-func QuadVertices(sx0, sy0, sx1, sy1 float32, a, b, c, d, tx, ty float32, cr, cg, cb, ca float32) []float32 {
+//
+// fill, if non-nil, is called once per corner to populate any custom
+// attributes registered via SetVertexLayout. fill can be nil if no custom
+// layout is registered, or the caller doesn't need to set those attributes.
+func QuadVertices(sx0, sy0, sx1, sy1 float32, a, b, c, d, tx, ty float32, cr, cg, cb, ca float32, fill QuadVertexFiller) []float32 {
 	x := sx1 - sx0
 	y := sy1 - sy0
 	ax, by, cx, dy := a*x, b*y, c*x, d*y
@@ -147,44 +360,37 @@ func QuadVertices(sx0, sy0, sx1, sy1 float32, a, b, c, d, tx, ty float32, cr, cg
 	// Use the vertex backend instead of calling make to reduce GCs (#1521).
 	vs := theVerticesBackend.slice(4)
 
+	// vfc is read once since VertexFloatCount is fixed for the lifetime of
+	// this pooled slice (the layout can't change once vertices are in use).
+	vfc := VertexFloatCount()
+
 	// This function is very performance-sensitive and implement in a very dumb way.
-	_ = vs[:4*VertexFloatCount]
-
-	vs[0] = adjustDestinationPixel(tx)
-	vs[1] = adjustDestinationPixel(ty)
-	vs[2] = u0
-	vs[3] = v0
-	vs[4] = cr
-	vs[5] = cg
-	vs[6] = cb
-	vs[7] = ca
-
-	vs[8] = adjustDestinationPixel(ax + tx)
-	vs[9] = adjustDestinationPixel(cx + ty)
-	vs[10] = u1
-	vs[11] = v0
-	vs[12] = cr
-	vs[13] = cg
-	vs[14] = cb
-	vs[15] = ca
-
-	vs[16] = adjustDestinationPixel(by + tx)
-	vs[17] = adjustDestinationPixel(dy + ty)
-	vs[18] = u0
-	vs[19] = v1
-	vs[20] = cr
-	vs[21] = cg
-	vs[22] = cb
-	vs[23] = ca
-
-	vs[24] = adjustDestinationPixel(ax + by + tx)
-	vs[25] = adjustDestinationPixel(cx + dy + ty)
-	vs[26] = u1
-	vs[27] = v1
-	vs[28] = cr
-	vs[29] = cg
-	vs[30] = cb
-	vs[31] = ca
+	_ = vs[:4*vfc]
+
+	corners := [4][4]float32{
+		{adjustDestinationPixel(tx), adjustDestinationPixel(ty), u0, v0},
+		{adjustDestinationPixel(ax + tx), adjustDestinationPixel(cx + ty), u1, v0},
+		{adjustDestinationPixel(by + tx), adjustDestinationPixel(dy + ty), u0, v1},
+		{adjustDestinationPixel(ax + by + tx), adjustDestinationPixel(cx + dy + ty), u1, v1},
+	}
+	for i, corner := range corners {
+		base := i * vfc
+		vs[base] = corner[0]
+		vs[base+1] = corner[1]
+		vs[base+2] = corner[2]
+		vs[base+3] = corner[3]
+		vs[base+4] = cr
+		vs[base+5] = cg
+		vs[base+6] = cb
+		vs[base+7] = ca
+	}
+
+	if fill != nil {
+		for i := 0; i < 4; i++ {
+			base := i*vfc + builtinVertexFloatCount
+			fill(i, vs[base:base+vfc-builtinVertexFloatCount])
+		}
+	}
 
 	return vs
 }
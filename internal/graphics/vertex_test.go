@@ -0,0 +1,105 @@
+// Copyright 2019 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"testing"
+)
+
+func TestQuadVerticesFillReceivesCustomAttributeOffsets(t *testing.T) {
+	var gotCorners []int
+	var gotLens []int
+	fill := func(corner int, dst []float32) {
+		gotCorners = append(gotCorners, corner)
+		gotLens = append(gotLens, len(dst))
+		for i := range dst {
+			dst[i] = float32(corner*10 + i)
+		}
+	}
+
+	vs := QuadVertices(0, 0, 1, 1, 1, 0, 0, 1, 0, 0, 1, 1, 1, 1, fill)
+
+	if len(gotCorners) != 4 {
+		t.Fatalf("fill was called %d times, want 4", len(gotCorners))
+	}
+	for i, c := range gotCorners {
+		if c != i {
+			t.Errorf("gotCorners[%d] = %d, want %d", i, c, i)
+		}
+	}
+
+	vfc := VertexFloatCount()
+	wantLen := vfc - builtinVertexFloatCount
+	for i, l := range gotLens {
+		if l != wantLen {
+			t.Errorf("gotLens[%d] = %d, want %d", i, l, wantLen)
+		}
+	}
+
+	for corner := 0; corner < 4; corner++ {
+		base := corner*vfc + builtinVertexFloatCount
+		for i := 0; i < wantLen; i++ {
+			want := float32(corner*10 + i)
+			if got := vs[base+i]; got != want {
+				t.Errorf("vs[%d] = %v, want %v", base+i, got, want)
+			}
+		}
+	}
+}
+
+func TestQuadVerticesNilFillLeavesBuiltinLayoutIntact(t *testing.T) {
+	vs := QuadVertices(0, 0, 1, 1, 1, 0, 0, 1, 0, 0, 0.1, 0.2, 0.3, 0.4, nil)
+	if len(vs) != 4*VertexFloatCount() {
+		t.Fatalf("len(vs) = %d, want %d", len(vs), 4*VertexFloatCount())
+	}
+	// The color block (offset 4..8 of each vertex) should be set regardless
+	// of fill.
+	for corner := 0; corner < 4; corner++ {
+		base := corner * VertexFloatCount()
+		got := vs[base+4 : base+8]
+		want := []float32{0.1, 0.2, 0.3, 0.4}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("corner %d color[%d] = %v, want %v", corner, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestIndicesDoNotOverlap(t *testing.T) {
+	a := Indices(3)
+	b := Indices(3)
+	for i := range a {
+		a[i] = 1
+	}
+	for i := range b {
+		b[i] = 2
+	}
+	for i, v := range a {
+		if v != 1 {
+			t.Fatalf("a[%d] = %d, want 1: writing to b must not have overlapped a's backing array", i, v)
+		}
+	}
+}
+
+func TestNewMeshSizesVerticesAndIndices(t *testing.T) {
+	m := NewMesh(5, 9)
+	if len(m.Vertices) != 5*VertexFloatCount() {
+		t.Errorf("len(m.Vertices) = %d, want %d", len(m.Vertices), 5*VertexFloatCount())
+	}
+	if len(m.Indices) != 9 {
+		t.Errorf("len(m.Indices) = %d, want 9", len(m.Indices))
+	}
+}
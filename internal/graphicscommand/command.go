@@ -87,10 +87,18 @@ func (q *commandQueue) appendIndices(indices []uint16, offset uint16) {
 
 // mustUseDifferentVertexBuffer reports whether a different vertex buffer must be used.
 func mustUseDifferentVertexBuffer(nextNumVertexFloats, nextNumIndices int) bool {
-	return nextNumVertexFloats > graphics.IndicesCount*graphics.VertexFloatCount || nextNumIndices > graphics.IndicesCount
+	return nextNumVertexFloats > graphics.IndicesCount*graphics.VertexFloatCount() || nextNumIndices > graphics.IndicesCount
 }
 
 // EnqueueDrawTrianglesCommand enqueues a drawing-image command.
+//
+// vertices and indices don't have to describe a quad: callers can submit an
+// arbitrary indexed mesh allocated via graphics.Vertices and graphics.Indices
+// (e.g. fans, strips, or tessellated vector shapes batched into a single
+// draw call). When the command can be merged into the last queued
+// drawTrianglesCommand, its indices are appended with the existing batch's
+// vertex count as a base-vertex offset, so meshes that share state are
+// merged without a separate draw call.
 func (q *commandQueue) EnqueueDrawTrianglesCommand(dst *Image, srcs [graphics.ShaderImageCount]*Image, offsets [graphics.ShaderImageCount - 1][2]float32, vertices []float32, indices []uint16, blend graphicsdriver.Blend, dstRegion, srcRegion graphicsdriver.Region, shader *Shader, uniforms [][]float32, evenOdd bool) {
 	if len(indices) > graphics.IndicesCount {
 		panic(fmt.Sprintf("graphicscommand: len(indices) must be <= graphics.IndicesCount but not at EnqueueDrawTrianglesCommand: len(indices): %d, graphics.IndicesCount: %d", len(indices), graphics.IndicesCount))
@@ -106,7 +114,7 @@ func (q *commandQueue) EnqueueDrawTrianglesCommand(dst *Image, srcs [graphics.Sh
 	// Assume that all the image sizes are same.
 	// Assume that the images are packed from the front in the slice srcs.
 	q.vertices = append(q.vertices, vertices...)
-	q.appendIndices(indices, uint16(q.tmpNumVertexFloats/graphics.VertexFloatCount))
+	q.appendIndices(indices, uint16(q.tmpNumVertexFloats/graphics.VertexFloatCount()))
 	q.tmpNumVertexFloats += len(vertices)
 	q.tmpNumIndices += len(indices)
 
@@ -143,6 +151,27 @@ func (q *commandQueue) lastVertices(n int) []float32 {
 	return q.vertices[len(q.vertices)-n : len(q.vertices)]
 }
 
+// EnqueueDispatchCompute enqueues a compute-shader dispatch command, e.g. for
+// particle simulation, image post-processing, or culling that needs to run
+// between draw batches.
+//
+// A dispatch command can write to storageBuffers or to images that a later
+// drawTrianglesCommand reads from, so it always starts a fresh vertex batch:
+// any draws queued after it won't be merged with draws queued before it.
+func (q *commandQueue) EnqueueDispatchCompute(shader *Shader, groupsX, groupsY, groupsZ int, storageBuffers []graphicsdriver.BufferID, uniforms [][]float32) {
+	q.tmpNumVertexFloats = 0
+	q.tmpNumIndices = 0
+
+	q.commands = append(q.commands, &dispatchComputeCommand{
+		shader:         shader,
+		groupsX:        groupsX,
+		groupsY:        groupsY,
+		groupsZ:        groupsZ,
+		storageBuffers: storageBuffers,
+		uniforms:       uniforms,
+	})
+}
+
 // Enqueue enqueues a drawing command other than a draw-triangles command.
 //
 // For a draw-triangles command, use EnqueueDrawTrianglesCommand.
@@ -162,6 +191,37 @@ func (q *commandQueue) Flush(graphicsDriver graphicsdriver.Graphics, endFrame bo
 	return
 }
 
+// nextCommandGroup looks at the leading commands in cs and reports how many
+// of them (nc) can be submitted together against a single SetVertices call,
+// along with the total vertex float count (nv) and index count (ne) a
+// drawTrianglesCommand in that range needs. A dispatchComputeCommand always
+// ends the group it's found in, since it may write to storage buffers or
+// images a later drawTrianglesCommand reads from.
+func nextCommandGroup(cs []command) (nc, nv, ne int) {
+	for _, c := range cs {
+		if dtc, ok := c.(*drawTrianglesCommand); ok {
+			if dtc.numIndices() > graphics.IndicesCount {
+				panic(fmt.Sprintf("graphicscommand: dtc.NumIndices() must be <= graphics.IndicesCount but not at Flush: dtc.NumIndices(): %d, graphics.IndicesCount: %d", dtc.numIndices(), graphics.IndicesCount))
+			}
+			if nc > 0 && mustUseDifferentVertexBuffer(nv+dtc.numVertices(), ne+dtc.numIndices()) {
+				break
+			}
+			nv += dtc.numVertices()
+			ne += dtc.numIndices()
+			nc++
+			continue
+		}
+		if _, ok := c.(*dispatchComputeCommand); ok {
+			if nc == 0 {
+				nc = 1
+			}
+			break
+		}
+		nc++
+	}
+	return nc, nv, ne
+}
+
 // flush must be called the main thread.
 func (q *commandQueue) flush(graphicsDriver graphicsdriver.Graphics, endFrame bool) (err error) {
 	// If endFrame is true, Begin/End should be called to ensure the framebuffer is swapped.
@@ -201,22 +261,7 @@ func (q *commandQueue) flush(graphicsDriver graphicsdriver.Graphics, endFrame bo
 
 	cs := q.commands
 	for len(cs) > 0 {
-		nv := 0
-		ne := 0
-		nc := 0
-		for _, c := range cs {
-			if dtc, ok := c.(*drawTrianglesCommand); ok {
-				if dtc.numIndices() > graphics.IndicesCount {
-					panic(fmt.Sprintf("graphicscommand: dtc.NumIndices() must be <= graphics.IndicesCount but not at Flush: dtc.NumIndices(): %d, graphics.IndicesCount: %d", dtc.numIndices(), graphics.IndicesCount))
-				}
-				if nc > 0 && mustUseDifferentVertexBuffer(nv+dtc.numVertices(), ne+dtc.numIndices()) {
-					break
-				}
-				nv += dtc.numVertices()
-				ne += dtc.numIndices()
-			}
-			nc++
-		}
+		nc, nv, ne := nextCommandGroup(cs)
 		if 0 < ne {
 			if err := graphicsDriver.SetVertices(vs[:nv], es[:ne]); err != nil {
 				return err
@@ -224,15 +269,14 @@ func (q *commandQueue) flush(graphicsDriver graphicsdriver.Graphics, endFrame bo
 			es = es[ne:]
 			vs = vs[nv:]
 		}
+		// indexOffset is restarted for each group, since each group corresponds to
+		// exactly one SetVertices call (or none, for a lone dispatch command).
 		indexOffset := 0
 		for _, c := range cs[:nc] {
 			if err := c.Exec(graphicsDriver, indexOffset); err != nil {
 				return err
 			}
 			debug.Logf("  %s\n", c)
-			// TODO: indexOffset should be reset if the command type is different
-			// from the previous one. This fix is needed when another drawing command is
-			// introduced than drawTrianglesCommand.
 			if dtc, ok := c.(*drawTrianglesCommand); ok {
 				indexOffset += dtc.numIndices()
 			}
@@ -381,9 +425,10 @@ func dstRegionFromVertices(vertices []float32) (minX, minY, maxX, maxY float32)
 	maxX = negInf32
 	maxY = negInf32
 
-	for i := 0; i < len(vertices)/graphics.VertexFloatCount; i++ {
-		x := vertices[graphics.VertexFloatCount*i]
-		y := vertices[graphics.VertexFloatCount*i+1]
+	vfc := graphics.VertexFloatCount()
+	for i := 0; i < len(vertices)/vfc; i++ {
+		x := vertices[vfc*i]
+		y := vertices[vfc*i+1]
 		if x < minX {
 			minX = x
 		}
@@ -407,6 +452,31 @@ func mightOverlapDstRegions(vertices1, vertices2 []float32) bool {
 	return minX1 < maxX2+mergin && minX2 < maxX1+mergin && minY1 < maxY2+mergin && minY2 < maxY1+mergin
 }
 
+// dispatchComputeCommand represents a command to dispatch a compute shader.
+type dispatchComputeCommand struct {
+	shader         *Shader
+	groupsX        int
+	groupsY        int
+	groupsZ        int
+	storageBuffers []graphicsdriver.BufferID
+	uniforms       [][]float32
+}
+
+func (c *dispatchComputeCommand) String() string {
+	return fmt.Sprintf("dispatch-compute: groups: (%d, %d, %d), num of storage buffers: %d", c.groupsX, c.groupsY, c.groupsZ, len(c.storageBuffers))
+}
+
+// Exec executes the dispatchComputeCommand. Dispatch itself is required to
+// insert any barrier needed before a subsequent drawTrianglesCommand can see
+// the results; see graphicsdriver.Graphics.Dispatch.
+//
+// On a backend without compute shader support, graphicsDriver.Dispatch
+// returns graphicsdriver.ErrUnsupported, which Exec passes straight through
+// to the caller of FlushCommands.
+func (c *dispatchComputeCommand) Exec(graphicsDriver graphicsdriver.Graphics, indexOffset int) error {
+	return graphicsDriver.Dispatch(c.shader.shader.ID(), c.groupsX, c.groupsY, c.groupsZ, c.storageBuffers, c.uniforms)
+}
+
 // writePixelsCommand represents a command to replace pixels of an image.
 type writePixelsCommand struct {
 	dst  *Image
@@ -514,11 +584,21 @@ func (c *newShaderCommand) String() string {
 
 // Exec executes a newShaderCommand.
 func (c *newShaderCommand) Exec(graphicsDriver graphicsdriver.Graphics, indexOffset int) error {
-	s, err := graphicsDriver.NewShader(c.ir)
+	// A cache hit skips IR translation and pipeline state creation entirely.
+	if s := theShaderCache.load(graphicsDriver, c.ir); s != nil {
+		c.result.shader = s
+		return nil
+	}
+
+	// Custom attributes registered via graphics.SetVertexLayout are passed
+	// alongside the IR so the shader frontend can expose them to a Kage
+	// vertex function as attribute-style inputs.
+	s, err := graphicsDriver.NewShader(c.ir, graphics.VertexLayoutAttributes())
 	if err != nil {
 		return err
 	}
 	c.result.shader = s
+	theShaderCache.store(graphicsDriver, c.ir)
 	return nil
 }
 
@@ -0,0 +1,80 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphicscommand
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/graphics"
+)
+
+func drawTriangles(nindices int) *drawTrianglesCommand {
+	return &drawTrianglesCommand{
+		vertices: make([]float32, nindices*graphics.VertexFloatCount()),
+		nindices: nindices,
+	}
+}
+
+func TestNextCommandGroupSplitsAroundDispatchCompute(t *testing.T) {
+	cs := []command{
+		drawTriangles(6),
+		&dispatchComputeCommand{},
+		drawTriangles(6),
+	}
+
+	nc, _, ne := nextCommandGroup(cs)
+	if nc != 1 {
+		t.Fatalf("nc = %d, want 1: a dispatch command must end the group it follows", nc)
+	}
+	if ne != 6 {
+		t.Fatalf("ne = %d, want 6", ne)
+	}
+
+	// The dispatch command itself starts the next group alone.
+	nc, _, ne = nextCommandGroup(cs[1:])
+	if nc != 1 {
+		t.Fatalf("nc = %d, want 1: a lone dispatch command must form its own group", nc)
+	}
+	if ne != 0 {
+		t.Fatalf("ne = %d, want 0", ne)
+	}
+
+	nc, _, ne = nextCommandGroup(cs[2:])
+	if nc != 1 {
+		t.Fatalf("nc = %d, want 1", nc)
+	}
+	if ne != 6 {
+		t.Fatalf("ne = %d, want 6", ne)
+	}
+}
+
+func TestNextCommandGroupMergesConsecutiveDrawTriangles(t *testing.T) {
+	cs := []command{
+		drawTriangles(6),
+		drawTriangles(6),
+		drawTriangles(6),
+	}
+
+	nc, nv, ne := nextCommandGroup(cs)
+	if nc != 3 {
+		t.Fatalf("nc = %d, want 3: consecutive draws with no dispatch between them share one group", nc)
+	}
+	if ne != 18 {
+		t.Fatalf("ne = %d, want 18", ne)
+	}
+	if nv != 18*graphics.VertexFloatCount() {
+		t.Fatalf("nv = %d, want %d", nv, 18*graphics.VertexFloatCount())
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphicscommand
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/graphics"
+	"github.com/hajimehoshi/ebiten/v2/internal/graphicsdriver"
+	"github.com/hajimehoshi/ebiten/v2/internal/shaderir"
+)
+
+const defaultShaderCacheMaxBytes = 64 * 1024 * 1024
+
+// shaderBinaryCompiler is implemented by graphics drivers that can compile a
+// shaderir.Program down to a driver-native binary blob suitable for caching.
+// attributes is the same custom vertex attribute list passed to NewShader,
+// since the compiled binary depends on it too. Drivers that don't implement
+// it simply always take the slow IR-to-native translation path.
+type shaderBinaryCompiler interface {
+	CompileShaderBinary(ir *shaderir.Program, attributes []graphics.VertexAttribute) ([]byte, error)
+}
+
+// shaderBinaryLoader is implemented by graphics drivers that can create a
+// Shader directly from a previously cached binary blob.
+type shaderBinaryLoader interface {
+	LoadShaderBinary(blob []byte) (graphicsdriver.Shader, error)
+}
+
+// driverVersioner is implemented by graphics drivers whose compiled shader
+// binaries aren't portable across driver versions or hardware (Metal and
+// DirectX pipeline state objects, notably). Its result is folded into the
+// cache key so that a driver update can't load a stale binary; it must also
+// identify the GPU/adapter if the binary isn't portable across hardware on
+// the same driver version. LoadShaderBinary must still reject any blob it
+// can't run, since a key collision can't be ruled out entirely.
+type driverVersioner interface {
+	DriverVersion() string
+}
+
+// ShaderCache persists compiled shader binaries on disk across process
+// launches, keyed by a hash of the shaderir.Program plus the driver kind and
+// version, so that a cache hit can skip IR translation and pipeline state
+// creation, both of which dominate first-frame cost on Metal and DirectX.
+type ShaderCache struct {
+	dir      string
+	maxBytes int64
+
+	m sync.Mutex
+}
+
+var theShaderCache = newDefaultShaderCache()
+
+func newDefaultShaderCache() *ShaderCache {
+	var dir string
+	if d, err := os.UserCacheDir(); err == nil {
+		dir = filepath.Join(d, "ebiten", "shaders")
+	}
+	return &ShaderCache{
+		dir:      dir,
+		maxBytes: defaultShaderCacheMaxBytes,
+	}
+}
+
+// SetShaderCache configures the directory and the maximum total size, in
+// bytes, used to persist compiled shader binaries. Once the cache exceeds
+// maxBytes, the least recently used entries are evicted first. Passing an
+// empty dir disables the cache; passing a non-positive maxBytes disables
+// eviction.
+func SetShaderCache(dir string, maxBytes int64) {
+	theShaderCache.m.Lock()
+	defer theShaderCache.m.Unlock()
+
+	theShaderCache.dir = dir
+	theShaderCache.maxBytes = maxBytes
+}
+
+// shaderCacheKey hashes ir (plus the driver kind, driver version, and
+// currently registered custom vertex attributes) into a key stable across
+// process launches. ir is serialized with encoding/json rather than %#v,
+// which only dereferences the top-level pointer and would print any nested
+// pointer (e.g. a *Block reachable from a Func) as its runtime address.
+func shaderCacheKey(graphicsDriver graphicsdriver.Graphics, ir *shaderir.Program) (string, error) {
+	irBytes, err := json.Marshal(ir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "driver-kind:%T\n", graphicsDriver)
+	if v, ok := graphicsDriver.(driverVersioner); ok {
+		fmt.Fprintf(h, "driver-version:%s\n", v.DriverVersion())
+	}
+	fmt.Fprintf(h, "attributes:%#v\n", graphics.VertexLayoutAttributes())
+	h.Write(irBytes)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// load returns a Shader created from a cached binary blob, or nil if there's
+// no usable cache entry, e.g. the driver doesn't support loading binaries,
+// the cache is disabled, or this is the first time ir has been seen.
+func (c *ShaderCache) load(graphicsDriver graphicsdriver.Graphics, ir *shaderir.Program) graphicsdriver.Shader {
+	loader, ok := graphicsDriver.(shaderBinaryLoader)
+	if !ok {
+		return nil
+	}
+
+	c.m.Lock()
+	dir := c.dir
+	c.m.Unlock()
+	if dir == "" {
+		return nil
+	}
+
+	key, err := shaderCacheKey(graphicsDriver, ir)
+	if err != nil {
+		return nil
+	}
+	path := filepath.Join(dir, key)
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	s, err := loader.LoadShaderBinary(blob)
+	if err != nil {
+		return nil
+	}
+
+	// Bump the modification time so the LRU eviction in store treats this
+	// entry as recently used.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return s
+}
+
+// store compiles ir to a driver-native binary and writes it to the cache
+// directory, then evicts old entries until the cache is back under its
+// configured size. It's a no-op if the driver can't compile binaries or the
+// cache is disabled.
+func (c *ShaderCache) store(graphicsDriver graphicsdriver.Graphics, ir *shaderir.Program) {
+	compiler, ok := graphicsDriver.(shaderBinaryCompiler)
+	if !ok {
+		return
+	}
+
+	c.m.Lock()
+	dir := c.dir
+	maxBytes := c.maxBytes
+	c.m.Unlock()
+	if dir == "" {
+		return
+	}
+
+	blob, err := compiler.CompileShaderBinary(ir, graphics.VertexLayoutAttributes())
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	key, err := shaderCacheKey(graphicsDriver, ir)
+	if err != nil {
+		return
+	}
+	path := filepath.Join(dir, key)
+	if err := os.WriteFile(path, blob, 0644); err != nil {
+		return
+	}
+
+	evictShaderCacheDir(dir, maxBytes)
+}
+
+// evictShaderCacheDir removes the least recently used files in dir until its
+// total size is at most maxBytes. A non-positive maxBytes disables eviction.
+func evictShaderCacheDir(dir string, maxBytes int64) {
+	if maxBytes <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{filepath.Join(dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	if total <= maxBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			continue
+		}
+		total -= f.size
+	}
+}
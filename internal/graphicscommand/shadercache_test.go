@@ -0,0 +1,65 @@
+// Copyright 2023 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphicscommand
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/graphicsdriver"
+	"github.com/hajimehoshi/ebiten/v2/internal/shaderir"
+)
+
+// fakeGraphicsDriver stands in for a real backend in tests that only need a
+// graphicsdriver.Graphics value to exist, not to do anything: its embedded
+// interface is left nil, so any method call on it would panic, but
+// shaderCacheKey never calls one.
+type fakeGraphicsDriver struct {
+	graphicsdriver.Graphics
+}
+
+func newEqualPrograms() (*shaderir.Program, *shaderir.Program) {
+	newProgram := func() *shaderir.Program {
+		return &shaderir.Program{
+			Funcs: []shaderir.Func{
+				{
+					Block: &shaderir.Block{},
+				},
+			},
+		}
+	}
+	return newProgram(), newProgram()
+}
+
+func TestShaderCacheKeyStableAcrossIndependentlyBuiltPrograms(t *testing.T) {
+	ir1, ir2 := newEqualPrograms()
+
+	var d fakeGraphicsDriver
+	key1, err := shaderCacheKey(d, ir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := shaderCacheKey(d, ir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ir1 and ir2 are separate allocations with equal content, including a
+	// nested *Block pointer. A key derived from fmt.Sprintf("%#v", ir) would
+	// differ between them, since %#v prints a nested pointer's address
+	// rather than its pointed-to value; shaderCacheKey must not.
+	if key1 != key2 {
+		t.Errorf("shaderCacheKey(d, ir1) = %q, shaderCacheKey(d, ir2) = %q; want equal keys for independently built equal programs", key1, key2)
+	}
+}
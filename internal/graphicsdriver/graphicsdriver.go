@@ -0,0 +1,124 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graphicsdriver defines the interface a platform backend (OpenGL,
+// Metal, DirectX, ...) implements so that graphicscommand can submit draw
+// and compute work without depending on any one backend.
+package graphicsdriver
+
+import (
+	"errors"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/graphics"
+	"github.com/hajimehoshi/ebiten/v2/internal/shaderir"
+)
+
+// ErrUnsupported is returned by a Graphics method when the underlying
+// backend doesn't support the requested feature, e.g. Dispatch on a GL or
+// Metal version without compute shader support. Callers can check for it
+// with errors.Is.
+var ErrUnsupported = errors.New("graphicsdriver: feature unsupported by the current driver")
+
+// ImageID is a unique identifier for an Image allocated by a Graphics.
+type ImageID int
+
+// InvalidImageID represents an out-of-range or absent ImageID, e.g. an
+// unused texture slot in a draw call.
+const InvalidImageID ImageID = -1
+
+// ShaderID is a unique identifier for a Shader allocated by a Graphics.
+type ShaderID int
+
+// InvalidShaderID represents an out-of-range or absent ShaderID.
+const InvalidShaderID ShaderID = -1
+
+// BufferID is a unique identifier for a storage buffer allocated by a
+// Graphics, used as a compute shader's input or output via Dispatch.
+type BufferID int
+
+// InvalidBufferID represents an out-of-range or absent BufferID.
+const InvalidBufferID BufferID = -1
+
+// Blend represents a blend mode for a draw call.
+type Blend struct {
+	BlendFactorSourceRGB        int
+	BlendFactorSourceAlpha      int
+	BlendFactorDestinationRGB   int
+	BlendFactorDestinationAlpha int
+	BlendOperationRGB           int
+	BlendOperationAlpha         int
+}
+
+// Region represents a rectangular region of an image, in pixels.
+type Region struct {
+	X      float32
+	Y      float32
+	Width  float32
+	Height float32
+}
+
+// WritePixelsArgs represents one region to be overwritten by WritePixels.
+type WritePixelsArgs struct {
+	Pixels []byte
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Image represents a backend-native image.
+type Image interface {
+	ID() ImageID
+	WritePixels(args []*WritePixelsArgs) error
+	ReadPixels(result []byte, x, y, width, height int) error
+	Dispose()
+	IsInvalidated() bool
+}
+
+// Shader represents a backend-native compiled shader.
+type Shader interface {
+	ID() ShaderID
+	Dispose()
+}
+
+// Graphics is implemented by a platform backend (OpenGL, Metal, DirectX,
+// ...) to execute the commands graphicscommand queues.
+type Graphics interface {
+	Begin() error
+	End(endFrame bool) error
+	Initialize() error
+	MaxImageSize() int
+	NewImage(width, height int) (Image, error)
+	NewScreenFramebufferImage(width, height int) (Image, error)
+
+	// NewShader compiles ir into a backend-native Shader. attributes
+	// describes any custom per-vertex attributes registered via
+	// graphics.SetVertexLayout, in addition to the built-in position,
+	// texture coordinate and color attributes, so the shader frontend can
+	// expose them to a Kage vertex function as attribute-style inputs.
+	NewShader(ir *shaderir.Program, attributes []graphics.VertexAttribute) (Shader, error)
+
+	SetVertices(vertices []float32, indices []uint16) error
+	DrawTriangles(dst ImageID, srcs [graphics.ShaderImageCount]ImageID, shader ShaderID, indexCount, indexOffset int, blend Blend, dstRegion Region, uniforms [][]float32, evenOdd bool) error
+
+	// Dispatch runs the compute shader identified by shaderID over
+	// (groupsX, groupsY, groupsZ) workgroups, with storageBuffers bound as
+	// its readable/writable buffers and uniforms as its uniform variables.
+	// Dispatch must not return until any barrier needed for a subsequent
+	// DrawTriangles or Dispatch call to see its writes has been issued;
+	// graphicscommand relies on this and emits no barrier of its own.
+	// Dispatch returns ErrUnsupported on a backend without compute shader
+	// support.
+	Dispatch(shaderID ShaderID, groupsX, groupsY, groupsZ int, storageBuffers []BufferID, uniforms [][]float32) error
+}